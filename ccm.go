@@ -25,16 +25,42 @@
 package aesccm
 
 import (
-	"bytes"
 	"crypto/cipher"
+	"crypto/subtle"
 	"errors"
+	"math"
+	"sync"
 )
 
 type ccm struct {
 	blockCipher cipher.Block
-	mac         *cbcMac
 	nonceSize   int
 	tagSize     int
+	star        bool
+	pool        sync.Pool
+	macPool     sync.Pool
+}
+
+// newScratchPool builds the sync.Pool of 16-byte scratch blocks shared by a ccm's Seal and Open
+// calls, used for the counter block, S0, and tag buffers to avoid allocating on every call.
+func newScratchPool() sync.Pool {
+	return sync.Pool{
+		New: func() interface{} {
+			return new([16]byte)
+		},
+	}
+}
+
+// newMACPool builds the sync.Pool of *cbcMac instances a ccm checks out for the lifetime of a
+// single Seal/Open call or streaming Seal/Open session, so that concurrent calls on the same
+// *ccm (and overlapping streams from the same CCMStream) each drive their own MAC state instead
+// of racing on a single shared one.
+func newMACPool(block cipher.Block) sync.Pool {
+	return sync.Pool{
+		New: func() interface{} {
+			return newCBCMACFromBlock(block)
+		},
+	}
 }
 
 var (
@@ -53,47 +79,145 @@ var (
 
 	// ErrAuthenticationFailed indicates that verifying the integrity of the decrypted message failed.
 	ErrAuthenticationFailed = errors.New("cipher: authentication failed for CCM mode")
+
+	// ErrStreamLengthMismatch indicates that the number of bytes written to a StreamSealer or
+	// StreamOpener doesn't match the plaintextLen declared to SealStream/OpenStream.
+	ErrStreamLengthMismatch = errors.New("cipher: streamed length doesn't match declared plaintextLen for CCM mode")
 )
 
-// getTag reuses a counter block for making the B0 block as per A.2 and A.3.
-func (c *ccm) getTag(ctr, data, plaintext []byte) []byte {
-	c.mac.Reset()
+// Note on additional-data length: CCM's encoding (RFC 3610 §2.2) can represent AAD lengths up to
+// 2^64-1 bytes, but a Go slice can never hold more than math.MaxInt (2^63-1 on every current
+// platform) bytes, so that ceiling can never actually be reached and isn't worth guarding against
+// here.
+
+// CCM is a cipher.AEAD implementing RFC 3610 / NIST SP 800-38C counter with CBC-MAC mode, with
+// the addition of MaxLength for callers that need to know the payload ceiling implied by their
+// chosen nonce size without re-deriving it.
+type CCM interface {
+	cipher.AEAD
+
+	// MaxLength returns the maximum number of plaintext bytes (for Open, ciphertext bytes
+	// excluding the tag) supported by this CCM instance's nonce size, i.e.
+	// 2^((15-NonceSize())*8) - 1 clamped to the platform's int range.
+	MaxLength() int
+}
+
+// primeMAC resets mac and writes the B0 block (formatted in place from ctr, which must hold a
+// counter block's [q-1] flags byte and nonce; its counter field is overwritten with Q) followed
+// by the additional-data length prefix and the additional
+// data itself, as per A.2 and A.3. It leaves mac ready to consume the plaintext, which getTag
+// does directly and the streaming API in ccm_stream.go does incrementally via Write. mac is
+// checked out of c.macPool by the caller so that concurrent/overlapping callers don't share MAC
+// state.
+func (c *ccm) primeMAC(mac *cbcMac, ctr, data []byte, plaintextLen int) {
+	mac.Reset()
 
-	cpy := ctr                                              // B0
-	cpy[0] |= byte(((c.tagSize - 2) / 2) << 3)              // [(t-2)/2]3
-	putUVarInt(cpy[1+c.nonceSize:], uint64(len(plaintext))) // Q
+	cpy := ctr                                            // B0
+	cpy[0] |= byte(((c.tagSize - 2) / 2) << 3)            // [(t-2)/2]3
+	putUVarInt(cpy[1+c.nonceSize:], uint64(plaintextLen)) // Q
 
 	if len(data) > 0 {
 		cpy[0] |= 1 << 6 // Adata
 
-		_, _ = c.mac.Write(cpy)
+		_, _ = mac.Write(cpy)
 
-		if len(data) < (1<<15 - 1<<7) {
+		if len(data) < (1<<16 - 1<<8) {
 			putUVarInt(cpy[:2], uint64(len(data)))
 
-			_, _ = c.mac.Write(cpy[:2])
+			_, _ = mac.Write(cpy[:2])
 		} else if len(data) <= 1<<31-1 {
 			cpy[0], cpy[1] = 0xff, 0xfe
 			putUVarInt(cpy[2:6], uint64(len(data)))
 
-			_, _ = c.mac.Write(cpy[:6])
+			_, _ = mac.Write(cpy[:6])
 		} else {
 			cpy[0], cpy[1] = 0xff, 0xff
 			putUVarInt(cpy[2:10], uint64(len(data)))
 
-			_, _ = c.mac.Write(cpy[:10])
+			_, _ = mac.Write(cpy[:10])
 		}
 
-		_, _ = c.mac.Write(data)
-		c.mac.PadZero()
+		_, _ = mac.Write(data)
+		mac.PadZero()
 	} else {
-		_, _ = c.mac.Write(cpy)
+		_, _ = mac.Write(cpy)
+	}
+}
+
+// getTag reuses a counter block for making the B0 block as per A.2 and A.3. dst is used as the
+// backing array for the returned tag; it must have a capacity of at least mac.Size() so that the
+// result of mac.Sum doesn't need to allocate.
+func (c *ccm) getTag(mac *cbcMac, dst, ctr, data, plaintext []byte) []byte {
+	c.primeMAC(mac, ctr, data, len(plaintext))
+
+	_, _ = mac.Write(plaintext)
+	mac.PadZero()
+
+	return mac.Sum(dst[:0])
+}
+
+// xorKeyStream drives the block cipher as CTR mode manually, one block at a time, XORing the
+// keystream into dst as it goes. counterBlock must already hold the starting counter value and is
+// incremented in place as blocks are consumed; it's reused as-is below rather than going through
+// cipher.NewCTR, which would allocate its own ~512-byte internal stream buffer on every call and
+// defeat the scratch pool's zero-allocation goal.
+func (c *ccm) xorKeyStream(dst, src, counterBlock []byte) {
+	ksPtr := c.getScratch()
+	defer c.putScratch(ksPtr)
+
+	ks := ksPtr[:]
+
+	for len(src) > 0 {
+		c.blockCipher.Encrypt(ks, counterBlock)
+
+		n := len(src)
+		if n > len(ks) {
+			n = len(ks)
+		}
+
+		xorBytes(dst[:n], src[:n], ks[:n])
+
+		dst = dst[n:]
+		src = src[n:]
+
+		incCounter(counterBlock)
+	}
+}
+
+// incCounter increments the 16-byte counter block in place as a single big-endian integer,
+// matching the counter semantics cipher.NewCTR uses internally.
+func incCounter(counterBlock []byte) {
+	for i := len(counterBlock) - 1; i >= 0; i-- {
+		counterBlock[i]++
+		if counterBlock[i] != 0 {
+			break
+		}
 	}
+}
 
-	_, _ = c.mac.Write(plaintext)
-	c.mac.PadZero()
+// getScratch returns a pooled, zeroed 16-byte scratch buffer for use as a counter block, S0, or
+// tag buffer within a single Seal/Open call.
+func (c *ccm) getScratch() *[16]byte {
+	return c.pool.Get().(*[16]byte)
+}
 
-	return c.mac.Sum(nil)
+// putScratch zeroes b and returns it to the pool.
+func (c *ccm) putScratch(b *[16]byte) {
+	*b = [16]byte{}
+	c.pool.Put(b)
+}
+
+// getMAC checks out a *cbcMac from c.macPool for exclusive use by a single Seal/Open call, or for
+// the lifetime of a streaming Seal/Open session (see ccm_stream.go). Callers must return it via
+// putMAC once done, and must not share a checked-out mac across concurrent operations.
+func (c *ccm) getMAC() *cbcMac {
+	return c.macPool.Get().(*cbcMac)
+}
+
+// putMAC resets mac and returns it to the pool.
+func (c *ccm) putMAC(mac *cbcMac) {
+	mac.Reset()
+	c.macPool.Put(mac)
 }
 
 func (c *ccm) NonceSize() int {
@@ -104,20 +228,51 @@ func (c *ccm) Overhead() int {
 	return c.tagSize
 }
 
+func (c *ccm) MaxLength() int {
+	max := maxUnsignedVarInt(15 - c.nonceSize)
+	if max > uint64(math.MaxInt) {
+		return math.MaxInt
+	}
+
+	return int(max)
+}
+
 func (c *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 	if len(nonce) != c.NonceSize() {
 		panic("cipher: incorrect nonce length given to CCM")
 	}
 
-	// can't return an error, return nil instead
+	// can't return an error, return nil instead; callers can check MaxLength up front to avoid
+	// hitting this case.
 	if maxUnsignedVarInt(15-c.nonceSize) < uint64(len(plaintext)) {
 		return nil
 	}
 
-	ret, ciphertext := sliceForAppend(dst, len(plaintext)+c.mac.Size())
+	// CCM* with a zero tag size is encryption-only: no CBC-MAC, no S0, no scratch space.
+	if c.star && c.tagSize == 0 {
+		ret, ciphertext := sliceForAppend(dst, len(plaintext))
+
+		counterBlockPtr := c.getScratch()
+		defer c.putScratch(counterBlockPtr)
+
+		// format counter blocks as defined in A.3
+		counterBlock := counterBlockPtr[:]
+		counterBlock[0] = byte(15 - c.nonceSize - 1) // [q-1]3
+		copy(counterBlock[1:], nonce)                // N
+		counterBlock[15] = 1                         // Ctr1
+
+		c.xorKeyStream(ciphertext, plaintext, counterBlock)
+
+		return ret
+	}
+
+	ret, ciphertext := sliceForAppend(dst, len(plaintext)+CbcMacBlockSize)
+
+	counterBlockPtr := c.getScratch()
+	defer c.putScratch(counterBlockPtr)
 
 	// format counter blocks as defined in A.3
-	counterBlock := make([]byte, 16)             // Ctr0
+	counterBlock := counterBlockPtr[:]
 	counterBlock[0] = byte(15 - c.nonceSize - 1) // [q-1]3
 	copy(counterBlock[1:], nonce)                // N
 
@@ -126,10 +281,15 @@ func (c *ccm) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
 
 	counterBlock[15] = 1 // Ctr1
 
-	ctr := cipher.NewCTR(c.blockCipher, counterBlock)
-	ctr.XORKeyStream(ciphertext, plaintext)
+	c.xorKeyStream(ciphertext, plaintext, counterBlock)
+
+	tagPtr := c.getScratch()
+	defer c.putScratch(tagPtr)
 
-	T := c.getTag(counterBlock, additionalData, plaintext)
+	mac := c.getMAC()
+	defer c.putMAC(mac)
+
+	T := c.getTag(mac, tagPtr[:], counterBlock, additionalData, plaintext)
 	xorBytes(s0, s0, T) // T ^ S0
 
 	return ret[:len(plaintext)+c.tagSize]
@@ -140,7 +300,32 @@ func (c *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error
 		return nil, ErrInvalidNonceSize
 	}
 
-	if len(ciphertext) <= c.tagSize {
+	// CCM* with a zero tag size is encryption-only: the ciphertext is exactly the plaintext
+	// length and there is no tag to verify.
+	if c.star && c.tagSize == 0 {
+		if maxUnsignedVarInt(15-c.nonceSize) < uint64(len(ciphertext)) {
+			return nil, ErrMaxPayloadSizeReached
+		}
+
+		ret, plaintext := sliceForAppend(dst, len(ciphertext))
+
+		counterBlockPtr := c.getScratch()
+		defer c.putScratch(counterBlockPtr)
+
+		// format counter blocks as defined in A.3
+		counterBlock := counterBlockPtr[:]
+		counterBlock[0] = byte(15 - c.nonceSize - 1) // [q-1]3
+		copy(counterBlock[1:], nonce)                // N
+		counterBlock[15] = 1                         // Ctr1
+
+		c.xorKeyStream(plaintext, ciphertext, counterBlock)
+
+		return ret, nil
+	}
+
+	// ciphertext must hold at least the tag; an empty plaintext is valid and leaves ciphertext
+	// exactly c.tagSize bytes long, so this must not reject len(ciphertext) == c.tagSize.
+	if len(ciphertext) < c.tagSize {
 		return nil, ErrInvalidTagSize
 	}
 
@@ -150,31 +335,47 @@ func (c *ccm) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error
 
 	ret, plaintext := sliceForAppend(dst, len(ciphertext)-c.tagSize)
 
+	counterBlockPtr := c.getScratch()
+	defer c.putScratch(counterBlockPtr)
+
 	// format counter blocks as defined in A.3
-	counterBlock := make([]byte, 16)             // Ctr0
+	counterBlock := counterBlockPtr[:]
 	counterBlock[0] = byte(15 - c.nonceSize - 1) // [q-1]3
 	copy(counterBlock[1:], nonce)                // N
 
-	s0 := make([]byte, 16) // S0
+	s0Ptr := c.getScratch()
+	defer c.putScratch(s0Ptr)
+
+	s0 := s0Ptr[:] // S0
 	c.blockCipher.Encrypt(s0, counterBlock)
 
 	counterBlock[15] = 1 // Ctr1
 
-	ctr := cipher.NewCTR(c.blockCipher, counterBlock)
-	ctr.XORKeyStream(plaintext, ciphertext[:len(plaintext)])
+	c.xorKeyStream(plaintext, ciphertext[:len(plaintext)], counterBlock)
+
+	tagPtr := c.getScratch()
+	defer c.putScratch(tagPtr)
 
-	T := c.getTag(counterBlock, additionalData, plaintext)
+	mac := c.getMAC()
+	defer c.putMAC(mac)
+
+	T := c.getTag(mac, tagPtr[:], counterBlock, additionalData, plaintext)
 	xorBytes(T, T, s0)
 
-	if !bytes.Equal(T[:c.tagSize], ciphertext[len(plaintext):]) {
+	if subtle.ConstantTimeCompare(T[:c.tagSize], ciphertext[len(plaintext):]) != 1 {
+		for i := range plaintext {
+			plaintext[i] = 0
+		}
+
 		return nil, ErrAuthenticationFailed
 	}
 
 	return ret, nil
 }
 
-// NewCCM creates a new AES-CCM cipher given the cipher block, nonce size and tag size.
-func NewCCM(block cipher.Block, nonceSize, tagSize int) (cipher.AEAD, error) {
+// newCCM validates the common CCM/CCM* construction parameters and builds the shared *ccm state;
+// NewCCM, NewCCMStar and NewCCMStream all funnel through it.
+func newCCM(block cipher.Block, nonceSize, tagSize int, star bool) (*ccm, error) {
 	if block.BlockSize() != CbcMacBlockSize {
 		return nil, ErrInvalidBlockSize
 	}
@@ -183,14 +384,45 @@ func NewCCM(block cipher.Block, nonceSize, tagSize int) (cipher.AEAD, error) {
 		return nil, ErrInvalidNonceSize
 	}
 
-	if !(4 <= tagSize && tagSize <= 16 && tagSize&1 == 0) {
+	validTagSize := 4 <= tagSize && tagSize <= 16 && tagSize&1 == 0
+	if star {
+		validTagSize = validTagSize || tagSize == 0
+	}
+
+	if !validTagSize {
 		return nil, ErrInvalidTagSize
 	}
 
 	return &ccm{
 		blockCipher: block,
-		mac:         newCBCMACFromBlock(block),
 		nonceSize:   nonceSize,
 		tagSize:     tagSize,
+		star:        star,
+		pool:        newScratchPool(),
+		macPool:     newMACPool(block),
 	}, nil
 }
+
+// NewCCM creates a new AES-CCM cipher given the cipher block, nonce size and tag size.
+func NewCCM(block cipher.Block, nonceSize, tagSize int) (CCM, error) {
+	c, err := newCCM(block, nonceSize, tagSize, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// NewCCMStar creates a new AES-CCM* cipher given the cipher block, nonce size and tag size.
+// CCM* is defined in IEEE 802.15.4 Annex B and used by ZigBee and Thread. It behaves exactly
+// like NewCCM, except that a tagSize of 0 is also permitted: the resulting cipher.AEAD performs
+// encryption only (no CBC-MAC, no authentication) and simply XORs the CTR keystream, so
+// Overhead() is 0 and Open accepts ciphertext of exactly the plaintext length.
+func NewCCMStar(block cipher.Block, nonceSize, tagSize int) (CCM, error) {
+	c, err := newCCM(block, nonceSize, tagSize, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
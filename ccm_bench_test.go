@@ -0,0 +1,118 @@
+/*
+ * MIT License
+ *
+ * Copyright (C) 2021 Crimson Technologies LLC. All rights reserved.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package aesccm
+
+import (
+	"crypto/aes"
+	"testing"
+)
+
+// benchmarkSeal and benchmarkOpen are modeled on crypto/cipher/benchmark_test.go's GCM
+// benchmarks, to keep regressions in allocation count and throughput visible across payload
+// sizes representative of DTLS/CoAP records up to bulk file-sized buffers.
+func benchmarkSeal(b *testing.B, c CCM, buf []byte) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+
+	nonce := make([]byte, c.NonceSize())
+	var ad [13]byte
+	var out []byte
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		out = c.Seal(out[:0], nonce, buf, ad[:])
+	}
+}
+
+func benchmarkOpen(b *testing.B, c CCM, buf []byte) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(buf)))
+
+	nonce := make([]byte, c.NonceSize())
+	var ad [13]byte
+
+	ct := c.Seal(nil, nonce, buf, ad[:])
+	var out []byte
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var err error
+
+		out, err = c.Open(out[:0], nonce, ct, ad[:])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func newBenchCCM(b *testing.B) CCM {
+	b.Helper()
+
+	block, err := aes.NewCipher(make([]byte, 16))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	c, err := NewCCM(block, 12, 16)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return c
+}
+
+func BenchmarkSeal_64(b *testing.B) {
+	benchmarkSeal(b, newBenchCCM(b), make([]byte, 64))
+}
+
+func BenchmarkSeal_1K(b *testing.B) {
+	benchmarkSeal(b, newBenchCCM(b), make([]byte, 1024))
+}
+
+func BenchmarkSeal_16K(b *testing.B) {
+	benchmarkSeal(b, newBenchCCM(b), make([]byte, 16*1024))
+}
+
+func BenchmarkSeal_1M(b *testing.B) {
+	benchmarkSeal(b, newBenchCCM(b), make([]byte, 1024*1024))
+}
+
+func BenchmarkOpen_64(b *testing.B) {
+	benchmarkOpen(b, newBenchCCM(b), make([]byte, 64))
+}
+
+func BenchmarkOpen_1K(b *testing.B) {
+	benchmarkOpen(b, newBenchCCM(b), make([]byte, 1024))
+}
+
+func BenchmarkOpen_16K(b *testing.B) {
+	benchmarkOpen(b, newBenchCCM(b), make([]byte, 16*1024))
+}
+
+func BenchmarkOpen_1M(b *testing.B) {
+	benchmarkOpen(b, newBenchCCM(b), make([]byte, 1024*1024))
+}
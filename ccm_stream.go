@@ -0,0 +1,235 @@
+/*
+ * MIT License
+ *
+ * Copyright (C) 2021 Crimson Technologies LLC. All rights reserved.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package aesccm
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"io"
+)
+
+// CCMStream is a streaming counterpart to CCM for callers that know their total plaintext length
+// up front (e.g. file encryption, SMB3 chunked writes) and want to avoid buffering the whole
+// payload in memory. CCM itself can't be a true online AEAD, since B0 encodes the plaintext
+// length, but given that length upfront the CBC-MAC and CTR keystream can both be driven
+// incrementally.
+type CCMStream interface {
+	// SealStream begins encrypting a message of exactly plaintextLen bytes under nonce and aad.
+	SealStream(nonce, aad []byte, plaintextLen int) (StreamSealer, error)
+
+	// OpenStream begins decrypting a message of exactly plaintextLen bytes under nonce and aad.
+	OpenStream(nonce, aad []byte, plaintextLen int) (StreamOpener, error)
+}
+
+// StreamSealer incrementally encrypts a single message in place: each Write transforms its
+// argument from plaintext to ciphertext without any intermediate buffering. The caller must
+// write exactly the plaintextLen bytes declared to SealStream before calling Close.
+type StreamSealer interface {
+	io.Writer
+
+	// Close finalizes the CBC-MAC and returns the truncated tag T ^ S0.
+	Close() (tag []byte, err error)
+}
+
+// StreamOpener incrementally decrypts a single message in place: each Write transforms its
+// argument from ciphertext to plaintext without any intermediate buffering. The caller must
+// write exactly the plaintextLen bytes declared to OpenStream before calling Close with the tag
+// read from the stream. As with any online AEAD, plaintext bytes are released to the caller
+// before the tag is verified; callers that can't tolerate that should buffer until Close
+// succeeds.
+type StreamOpener interface {
+	io.Writer
+
+	// Close verifies tag, the authentication tag read from the stream, in constant time against
+	// the data written so far.
+	Close(tag []byte) error
+}
+
+// newStreamCounterBlock builds Ctr0 from nonce and returns it alongside S0 = E_K(Ctr0).
+func (c *ccm) newStreamCounterBlock(nonce []byte) (counterBlock, s0 []byte) {
+	counterBlock = make([]byte, 16)
+	counterBlock[0] = byte(15 - c.nonceSize - 1) // [q-1]3
+	copy(counterBlock[1:], nonce)                // N
+
+	s0 = make([]byte, 16)
+	c.blockCipher.Encrypt(s0, counterBlock)
+
+	return counterBlock, s0
+}
+
+// SealStream begins encrypting a message of exactly plaintextLen bytes under nonce and aad; see
+// StreamSealer.
+func (c *ccm) SealStream(nonce, aad []byte, plaintextLen int) (StreamSealer, error) {
+	if len(nonce) != c.nonceSize {
+		panic("cipher: incorrect nonce length given to CCM")
+	}
+
+	if plaintextLen < 0 || maxUnsignedVarInt(15-c.nonceSize) < uint64(plaintextLen) {
+		return nil, ErrMaxPayloadSizeReached
+	}
+
+	counterBlock, s0 := c.newStreamCounterBlock(nonce)
+
+	mac := c.getMAC()
+
+	b0 := make([]byte, 16)
+	copy(b0, counterBlock)
+	c.primeMAC(mac, b0, aad, plaintextLen)
+
+	counterBlock[15] = 1 // Ctr1
+
+	return &ccmStreamSealer{
+		c:            c,
+		mac:          mac,
+		ctr:          cipher.NewCTR(c.blockCipher, counterBlock),
+		s0:           s0,
+		plaintextLen: plaintextLen,
+	}, nil
+}
+
+// OpenStream begins decrypting a message of exactly plaintextLen bytes under nonce and aad; see
+// StreamOpener.
+func (c *ccm) OpenStream(nonce, aad []byte, plaintextLen int) (StreamOpener, error) {
+	if len(nonce) != c.nonceSize {
+		return nil, ErrInvalidNonceSize
+	}
+
+	if plaintextLen < 0 || maxUnsignedVarInt(15-c.nonceSize) < uint64(plaintextLen) {
+		return nil, ErrMaxPayloadSizeReached
+	}
+
+	counterBlock, s0 := c.newStreamCounterBlock(nonce)
+
+	mac := c.getMAC()
+
+	b0 := make([]byte, 16)
+	copy(b0, counterBlock)
+	c.primeMAC(mac, b0, aad, plaintextLen)
+
+	counterBlock[15] = 1 // Ctr1
+
+	return &ccmStreamOpener{
+		c:            c,
+		mac:          mac,
+		ctr:          cipher.NewCTR(c.blockCipher, counterBlock),
+		s0:           s0,
+		plaintextLen: plaintextLen,
+	}, nil
+}
+
+// ccmStreamSealer owns a *cbcMac checked out of c.macPool for its entire lifetime, rather than
+// sharing one MAC state across streams, so that multiple streams opened from the same CCMStream before
+// either is Close'd don't clobber each other's MAC (e.g. concurrent SMB3 chunked writes sharing
+// one cipher).
+type ccmStreamSealer struct {
+	c            *ccm
+	mac          *cbcMac
+	ctr          cipher.Stream
+	s0           []byte
+	plaintextLen int
+	written      int
+}
+
+func (s *ccmStreamSealer) Write(p []byte) (int, error) {
+	if s.written+len(p) > s.plaintextLen {
+		return 0, ErrStreamLengthMismatch
+	}
+
+	_, _ = s.mac.Write(p) // MAC the plaintext before it's overwritten below
+	s.ctr.XORKeyStream(p, p)
+
+	s.written += len(p)
+
+	return len(p), nil
+}
+
+func (s *ccmStreamSealer) Close() ([]byte, error) {
+	defer s.c.putMAC(s.mac)
+
+	if s.written != s.plaintextLen {
+		return nil, ErrStreamLengthMismatch
+	}
+
+	s.mac.PadZero()
+
+	tag := s.mac.Sum(nil)
+	xorBytes(tag, tag, s.s0) // T ^ S0
+
+	return tag[:s.c.tagSize], nil
+}
+
+// ccmStreamOpener owns a *cbcMac checked out of c.macPool for its entire lifetime; see
+// ccmStreamSealer.
+type ccmStreamOpener struct {
+	c            *ccm
+	mac          *cbcMac
+	ctr          cipher.Stream
+	s0           []byte
+	plaintextLen int
+	written      int
+}
+
+func (o *ccmStreamOpener) Write(p []byte) (int, error) {
+	if o.written+len(p) > o.plaintextLen {
+		return 0, ErrStreamLengthMismatch
+	}
+
+	o.ctr.XORKeyStream(p, p)
+	_, _ = o.mac.Write(p) // MAC the plaintext now that p has been decrypted in place
+
+	o.written += len(p)
+
+	return len(p), nil
+}
+
+func (o *ccmStreamOpener) Close(tag []byte) error {
+	defer o.c.putMAC(o.mac)
+
+	if o.written != o.plaintextLen {
+		return ErrStreamLengthMismatch
+	}
+
+	o.mac.PadZero()
+
+	T := o.mac.Sum(nil)
+	xorBytes(T, T, o.s0) // T ^ S0
+
+	if subtle.ConstantTimeCompare(T[:o.c.tagSize], tag) != 1 {
+		return ErrAuthenticationFailed
+	}
+
+	return nil
+}
+
+// NewCCMStream creates a new streaming AES-CCM cipher given the cipher block, nonce size and tag
+// size; see CCMStream.
+func NewCCMStream(block cipher.Block, nonceSize, tagSize int) (CCMStream, error) {
+	c, err := newCCM(block, nonceSize, tagSize, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
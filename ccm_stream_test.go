@@ -0,0 +1,120 @@
+/*
+ * MIT License
+ *
+ * Copyright (C) 2021 Crimson Technologies LLC. All rights reserved.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package aesccm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+)
+
+func TestCCMStreamRoundTrip(t *testing.T) {
+	key := make([]byte, 16)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCCMStream(block, 12, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, 12)
+	aad := []byte("streamed associated data")
+	plaintext := bytes.Repeat([]byte("streamed plaintext chunk/"), 100)
+
+	sealer, err := c.SealStream(nonce, aad, len(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// work is transformed from plaintext to ciphertext in place, chunk by chunk; keep the
+	// original plaintext separately so it can be compared against the round-tripped result.
+	work := append([]byte(nil), plaintext...)
+
+	// write in uneven chunks to exercise the incremental MAC/CTR state across Write calls
+	for off, chunkLens := 0, []int{7, 31, 1}; off < len(work); {
+		n := chunkLens[off%len(chunkLens)]
+		if off+n > len(work) {
+			n = len(work) - off
+		}
+
+		if _, err := sealer.Write(work[off : off+n]); err != nil {
+			t.Fatal(err)
+		}
+
+		off += n
+	}
+
+	tag, err := sealer.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opener, err := c.OpenStream(nonce, aad, len(work))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := opener.Write(work); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := opener.Close(tag); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if !bytes.Equal(work, plaintext) {
+		t.Fatalf("decrypted = %q, want %q", work, plaintext)
+	}
+}
+
+func TestCCMStreamLengthMismatch(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewCCMStream(block, 12, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nonce := make([]byte, 12)
+
+	sealer, err := c.SealStream(nonce, nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sealer.Close(); err != ErrStreamLengthMismatch {
+		t.Fatalf("Close() error = %v, want %v", err, ErrStreamLengthMismatch)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * MIT License
+ *
+ * Copyright (C) 2021 Crimson Technologies LLC. All rights reserved.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package aesccm
+
+import "crypto/cipher"
+
+// cmac is an implementation of CMAC (RFC 4493, NIST SP 800-38B), also known as OMAC1. Unlike
+// cbcMac, which is only secure for fixed-length messages, cmac derives two subkeys from the
+// cipher and uses them to bind the final block, making it secure for variable-length messages.
+type cmac struct {
+	ci     []byte // chaining value of all but the last block
+	buf    []byte // the last (possibly partial) block, held back until Sum
+	n      int    // number of valid bytes in buf
+	block  cipher.Block
+	k1, k2 []byte // subkeys derived from the block cipher, per RFC 4493 §2.3
+}
+
+func (mac *cmac) Write(p []byte) (n int, err error) {
+	for _, c := range p {
+		if mac.n >= len(mac.buf) {
+			xorBytes(mac.ci, mac.ci, mac.buf)
+			mac.block.Encrypt(mac.ci, mac.ci)
+			mac.n = 0
+		}
+
+		mac.buf[mac.n] = c
+		mac.n++
+	}
+
+	return len(p), nil
+}
+
+func (mac *cmac) Sum(b []byte) []byte {
+	final := make([]byte, len(mac.buf))
+	copy(final, mac.buf[:mac.n])
+
+	if mac.n == len(mac.buf) {
+		xorBytes(final, final, mac.k1)
+	} else {
+		final[mac.n] = 0x80
+		xorBytes(final, final, mac.k2)
+	}
+
+	xorBytes(final, final, mac.ci)
+	mac.block.Encrypt(final, final)
+
+	return append(b, final...)
+}
+
+func (mac *cmac) Reset() {
+	for i := range mac.ci {
+		mac.ci[i] = 0
+	}
+
+	for i := range mac.buf {
+		mac.buf[i] = 0
+	}
+
+	mac.n = 0
+}
+
+func (mac *cmac) Size() int {
+	return len(mac.ci)
+}
+
+func (mac *cmac) BlockSize() int {
+	return CbcMacBlockSize
+}
+
+// gfDouble computes 2*in in GF(2^128) as defined by the modulus x^128+x^7+x^2+x+1 (RFC 4493
+// §2.3), i.e. a left shift of the 128-bit big-endian value in, reduced by the irreducible
+// polynomial 0x87 when the shift overflows.
+func gfDouble(in []byte) []byte {
+	out := make([]byte, len(in))
+	overflow := in[0] >> 7
+
+	for i := 0; i < len(in)-1; i++ {
+		out[i] = in[i]<<1 | in[i+1]>>7
+	}
+
+	out[len(out)-1] = in[len(in)-1] << 1
+
+	if overflow == 1 {
+		out[len(out)-1] ^= 0x87
+	}
+
+	return out
+}
+
+// newCMACFromBlock creates a new cmac from the specified cipher.Block, deriving the K1/K2
+// subkeys via GF(2^128) doubling of E_K(0) as per RFC 4493 §2.3.
+func newCMACFromBlock(block cipher.Block) *cmac {
+	l := make([]byte, block.BlockSize())
+	block.Encrypt(l, make([]byte, block.BlockSize()))
+
+	k1 := gfDouble(l)
+	k2 := gfDouble(k1)
+
+	return &cmac{
+		ci:    make([]byte, block.BlockSize()),
+		buf:   make([]byte, block.BlockSize()),
+		block: block,
+		k1:    k1,
+		k2:    k2,
+	}
+}
@@ -0,0 +1,188 @@
+/*
+ * MIT License
+ *
+ * Copyright (C) 2021 Crimson Technologies LLC. All rights reserved.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package aesccm
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"sync"
+)
+
+type eax struct {
+	blockCipher cipher.Block
+	macPool     sync.Pool
+	nonceSize   int
+	tagSize     int
+}
+
+// newEAXMACPool builds the sync.Pool of *cmac instances an eax checks out for the lifetime of a
+// single Seal/Open call, mirroring ccm's macPool: a single shared *cmac mutated in place would
+// race across concurrent Seal/Open calls on the same *eax, since N/H/C are each computed by
+// resetting and re-driving the same MAC state.
+func newEAXMACPool(block cipher.Block) sync.Pool {
+	return sync.Pool{
+		New: func() interface{} {
+			return newCMACFromBlock(block)
+		},
+	}
+}
+
+// ErrInvalidEAXNonceSize indicates that the nonce size is invalid for EAX mode.
+var ErrInvalidEAXNonceSize = errors.New("cipher: invalid nonce size for EAX mode")
+
+// omac computes OMAC_K^t(data) = CMAC_K([t]_n || data), the tweaked CMAC variant EAX uses to
+// derive N, H and C from a single underlying key (Bellare-Rogaway-Wagner §4). mac is checked out
+// of e.macPool by the caller so that concurrent callers don't share MAC state.
+func (e *eax) omac(mac *cmac, t byte, data, dst []byte) []byte {
+	mac.Reset()
+
+	prefix := make([]byte, mac.BlockSize())
+	prefix[len(prefix)-1] = t
+
+	_, _ = mac.Write(prefix)
+	_, _ = mac.Write(data)
+
+	return mac.Sum(dst[:0])
+}
+
+// getMAC checks out a *cmac from e.macPool for exclusive use by a single Seal/Open call. Callers
+// must return it via putMAC once done, and must not share a checked-out mac across concurrent
+// operations.
+func (e *eax) getMAC() *cmac {
+	return e.macPool.Get().(*cmac)
+}
+
+// putMAC resets mac and returns it to the pool.
+func (e *eax) putMAC(mac *cmac) {
+	mac.Reset()
+	e.macPool.Put(mac)
+}
+
+func (e *eax) NonceSize() int {
+	return e.nonceSize
+}
+
+func (e *eax) Overhead() int {
+	return e.tagSize
+}
+
+func (e *eax) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != e.nonceSize {
+		panic("cipher: incorrect nonce length given to EAX")
+	}
+
+	ret, out := sliceForAppend(dst, len(plaintext)+e.tagSize)
+	ciphertext := out[:len(plaintext)]
+
+	mac := e.getMAC()
+	defer e.putMAC(mac)
+
+	var nBuf [16]byte
+
+	N := e.omac(mac, 0, nonce, nBuf[:])
+
+	ctrBlock := make([]byte, mac.BlockSize())
+	copy(ctrBlock, N)
+
+	ctr := cipher.NewCTR(e.blockCipher, ctrBlock)
+	ctr.XORKeyStream(ciphertext, plaintext)
+
+	var hBuf, cBuf, tag [16]byte
+
+	H := e.omac(mac, 1, additionalData, hBuf[:])
+	C := e.omac(mac, 2, ciphertext, cBuf[:])
+
+	xorBytes(tag[:], N, H)
+	xorBytes(tag[:], tag[:], C)
+
+	copy(out[len(plaintext):], tag[:e.tagSize])
+
+	return ret
+}
+
+func (e *eax) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != e.nonceSize {
+		return nil, ErrInvalidEAXNonceSize
+	}
+
+	if len(ciphertext) < e.tagSize {
+		return nil, ErrInvalidTagSize
+	}
+
+	ct := ciphertext[:len(ciphertext)-e.tagSize]
+	gotTag := ciphertext[len(ciphertext)-e.tagSize:]
+
+	mac := e.getMAC()
+	defer e.putMAC(mac)
+
+	var nBuf, hBuf, cBuf, tag [16]byte
+
+	N := e.omac(mac, 0, nonce, nBuf[:])
+	H := e.omac(mac, 1, additionalData, hBuf[:])
+	C := e.omac(mac, 2, ct, cBuf[:])
+
+	xorBytes(tag[:], N, H)
+	xorBytes(tag[:], tag[:], C)
+
+	if subtle.ConstantTimeCompare(tag[:e.tagSize], gotTag) != 1 {
+		return nil, ErrAuthenticationFailed
+	}
+
+	ret, plaintext := sliceForAppend(dst, len(ct))
+
+	ctrBlock := make([]byte, mac.BlockSize())
+	copy(ctrBlock, N)
+
+	ctr := cipher.NewCTR(e.blockCipher, ctrBlock)
+	ctr.XORKeyStream(plaintext, ct)
+
+	return ret, nil
+}
+
+// NewEAX creates a new AES-EAX cipher (Bellare-Rogaway-Wagner) given the cipher block, nonce
+// size and tag size. EAX reuses this package's CBC-based building blocks, upgraded to CMAC so
+// that the same MAC key is safely reusable across the N/H/C computations, and unlike CCM it
+// supports arbitrary nonce lengths rather than the 7-13 byte range RFC 3610 requires.
+func NewEAX(block cipher.Block, nonceSize, tagSize int) (cipher.AEAD, error) {
+	if block.BlockSize() != CbcMacBlockSize {
+		return nil, ErrInvalidBlockSize
+	}
+
+	if nonceSize <= 0 {
+		return nil, ErrInvalidEAXNonceSize
+	}
+
+	if !(4 <= tagSize && tagSize <= 16) {
+		return nil, ErrInvalidTagSize
+	}
+
+	return &eax{
+		blockCipher: block,
+		macPool:     newEAXMACPool(block),
+		nonceSize:   nonceSize,
+		tagSize:     tagSize,
+	}, nil
+}
@@ -0,0 +1,79 @@
+/*
+ * MIT License
+ *
+ * Copyright (C) 2021 Crimson Technologies LLC. All rights reserved.
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy
+ * of this software and associated documentation files (the "Software"), to deal
+ * in the Software without restriction, including without limitation the rights
+ * to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+ * copies of the Software, and to permit persons to whom the Software is
+ * furnished to do so, subject to the following conditions:
+ *
+ * The above copyright notice and this permission notice shall be included in all
+ * copies or substantial portions of the Software.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+ * IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+ * FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+ * AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+ * LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+ * OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+ * SOFTWARE.
+ */
+
+package aesccm
+
+import "math"
+
+// putUVarInt writes v as a fixed-width big-endian integer into buf, using exactly len(buf)
+// bytes (left-padded with zeros). It's used to encode the CCM Q and additional-data length
+// fields, which RFC 3610 defines as fixed-width big-endian integers of varying widths rather
+// than the variable-length encoding encoding/binary's Put(U)varint produces.
+func putUVarInt(buf []byte, v uint64) {
+	for i := len(buf) - 1; i >= 0; i-- {
+		buf[i] = byte(v)
+		v >>= 8
+	}
+}
+
+// maxUnsignedVarInt returns the largest value representable in an n-byte fixed-width unsigned
+// big-endian integer, i.e. 2^(8n) - 1, saturating at math.MaxUint64 for n >= 8 to avoid
+// overflowing the uint64 shift.
+func maxUnsignedVarInt(n int) uint64 {
+	if n >= 8 {
+		return math.MaxUint64
+	}
+
+	return 1<<uint(n*8) - 1
+}
+
+// xorBytes sets dst[i] = x[i] ^ y[i] for the first min(len(x), len(y)) bytes. The caller is
+// responsible for ensuring dst is at least that long.
+func xorBytes(dst, x, y []byte) {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+
+	for i := 0; i < n; i++ {
+		dst[i] = x[i] ^ y[i]
+	}
+}
+
+// sliceForAppend extends in by n bytes, returning the extended slice and the newly added
+// portion, reusing in's existing capacity when there's room rather than always allocating. This
+// is the same pattern crypto/cipher's AEAD implementations (e.g. GCM) use for append-style
+// Seal/Open signatures.
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+
+	tail = head[len(in):]
+
+	return
+}